@@ -0,0 +1,166 @@
+package influxdata
+
+import (
+	"fmt"
+	"io"
+)
+
+// Character classes and escapers for the grammar of a line-protocol line:
+//
+//	measurement[,tag_key=tag_value...] field_key=field_value[,field_key=field_value...] [timestamp]
+var (
+	measurementStop = newByteSet(' ', ',', '\n').invert()
+	measurementEsc  = newEscaper(` ,`)
+
+	tagValueStop = newByteSet(' ', ',', '\n').invert()
+	tagEsc       = newEscaper(` ,=`)
+
+	fieldKeyStop = newByteSet('=', ' ', ',', '\n').invert()
+	fieldKeyEsc  = newEscaper(` ,=`)
+
+	fieldValueStop  = newByteSet(' ', ',', '\n').invert()
+	stringValueStop = newByteSet('"').invert()
+	stringValueEsc  = newEscaper(`"\`)
+
+	timestampSet = newByteSet('-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9')
+
+	newlineSet = newByteSet('\n').invert()
+)
+
+// fieldPair holds one field_key=field_value pair, buffered until a line's
+// timestamp has been parsed.
+type fieldPair struct {
+	key, val []byte
+}
+
+// TokenizeFunc parses r as a stream of InfluxDB line-protocol lines,
+// calling fn once for each tag and once for each field of every line.
+//
+// For a tag, fn is called as fn(measurement, tagKey, tagVal, nil, nil, nil);
+// for a field, as fn(measurement, nil, nil, fieldKey, fieldVal, ts), where
+// ts holds the line's raw timestamp bytes (nil if the line had none). Tag
+// calls for a line always precede its field calls. A line with no fields
+// is an error, as in the line-protocol grammar.
+//
+// As with bufio.Scanner's split functions, none of the byte slices passed
+// to fn may be retained past the call: TokenizeFunc reuses its buffers for
+// subsequent tags, fields and lines, and promises only that each slice is
+// stable for the duration of the call it's passed to, even if doing so
+// requires growing an internal buffer partway through a line.
+//
+// TokenizeFunc returns the first error encountered, whether from r, from
+// a malformed line, or from fn; fn's errors are returned unwrapped.
+func TokenizeFunc(r io.Reader, fn func(measurement, tagKey, tagVal, fieldKey, fieldVal, ts []byte) error) error {
+	t := NewTokenizer(r)
+	var fieldBuf []byte
+	var fields []fieldPair
+	for {
+		t.reset()
+		if !skipBlankLines(t) {
+			break
+		}
+		measurement := t.takeEsc(measurementStop, &measurementEsc.revTable)
+		if len(measurement) == 0 {
+			return t.lineErrorf("missing measurement")
+		}
+		for t.ensure(1) && t.at(0) == ',' {
+			t.advance(1)
+			tagKey := t.takeEsc(fieldKeyStop, &tagEsc.revTable)
+			if !t.ensure(1) || t.at(0) != '=' {
+				return t.lineErrorf("tag %q without a value", tagKey)
+			}
+			t.advance(1)
+			tagVal := t.takeEsc(tagValueStop, &tagEsc.revTable)
+			if err := fn(measurement, tagKey, tagVal, nil, nil, nil); err != nil {
+				return err
+			}
+		}
+		if !t.ensure(1) || t.at(0) != ' ' {
+			return t.lineErrorf("missing fields")
+		}
+		t.advance(1)
+
+		fieldBuf = fieldBuf[:0]
+		fields = fields[:0]
+		for {
+			fieldKey := t.TakeInto(&fieldBuf, fieldKeyStop, &fieldKeyEsc.revTable)
+			if !t.ensure(1) || t.at(0) != '=' {
+				return t.lineErrorf("field %q without a value", fieldKey)
+			}
+			t.advance(1)
+			var fieldVal []byte
+			if t.ensure(1) && t.at(0) == '"' {
+				t.advance(1)
+				fieldVal = t.TakeInto(&fieldBuf, stringValueStop, &stringValueEsc.revTable)
+				if !t.ensure(1) || t.at(0) != '"' {
+					return t.lineErrorf("unterminated string field value")
+				}
+				t.advance(1)
+			} else {
+				start := len(fieldBuf)
+				fieldBuf = append(fieldBuf, t.take(fieldValueStop)...)
+				fieldVal = fieldBuf[start:]
+			}
+			fields = append(fields, fieldPair{key: fieldKey, val: fieldVal})
+			if !t.ensure(1) || t.at(0) != ',' {
+				break
+			}
+			t.advance(1)
+		}
+
+		var ts []byte
+		if t.ensure(1) && t.at(0) == ' ' {
+			t.advance(1)
+			ts = t.take(timestampSet)
+		}
+		if t.ensure(1) && t.at(0) != '\n' {
+			return t.lineErrorf("unexpected character %q at end of line", t.at(0))
+		}
+		if t.ensure(1) {
+			t.advance(1)
+		}
+		for _, f := range fields {
+			if err := fn(measurement, nil, nil, f.key, f.val, ts); err != nil {
+				return err
+			}
+		}
+		if t.err != nil {
+			return t.err
+		}
+		if !t.ensure(1) {
+			break
+		}
+	}
+	return t.err
+}
+
+// skipBlankLines advances past any blank lines and comment lines (those
+// starting with '#', as used for line-protocol metadata) and reports
+// whether there's a line left to parse.
+func skipBlankLines(t *Tokenizer) bool {
+	for {
+		if !t.ensure(1) {
+			return false
+		}
+		switch t.at(0) {
+		case '\n':
+			t.advance(1)
+			t.reset()
+			continue
+		case '#':
+			t.take(newlineSet)
+			if t.ensure(1) {
+				t.advance(1)
+			}
+			t.reset()
+			continue
+		}
+		return true
+	}
+}
+
+// lineErrorf returns an error describing a problem found while parsing
+// the current line.
+func (t *Tokenizer) lineErrorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line-protocol: "+format, args...)
+}
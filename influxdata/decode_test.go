@@ -0,0 +1,92 @@
+package influxdata
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// call records a single invocation of the callback passed to TokenizeFunc.
+type call struct {
+	Measurement, TagKey, TagVal, FieldKey, FieldVal, TS string
+}
+
+func tokenizeFunc(c *qt.C, s string) ([]call, error) {
+	var calls []call
+	err := TokenizeFunc(strings.NewReader(s), func(measurement, tagKey, tagVal, fieldKey, fieldVal, ts []byte) error {
+		calls = append(calls, call{
+			Measurement: string(measurement),
+			TagKey:      string(tagKey),
+			TagVal:      string(tagVal),
+			FieldKey:    string(fieldKey),
+			FieldVal:    string(fieldVal),
+			TS:          string(ts),
+		})
+		return nil
+	})
+	return calls, err
+}
+
+func TestTokenizeFunc(t *testing.T) {
+	c := qt.New(t)
+	calls, err := tokenizeFunc(c, "cpu,host=a,region=us value=1,msg=\"hi there\" 100\ncpu value=2i\n")
+	c.Assert(err, qt.IsNil)
+	c.Assert(calls, qt.DeepEquals, []call{
+		{Measurement: "cpu", TagKey: "host", TagVal: "a"},
+		{Measurement: "cpu", TagKey: "region", TagVal: "us"},
+		{Measurement: "cpu", FieldKey: "value", FieldVal: "1", TS: "100"},
+		{Measurement: "cpu", FieldKey: "msg", FieldVal: "hi there", TS: "100"},
+		{Measurement: "cpu", FieldKey: "value", FieldVal: "2i"},
+	})
+}
+
+func TestTokenizeFuncSkipsBlankAndCommentLines(t *testing.T) {
+	c := qt.New(t)
+	calls, err := tokenizeFunc(c, "# a comment\n\ncpu value=1\n\n# another\n")
+	c.Assert(err, qt.IsNil)
+	c.Assert(calls, qt.DeepEquals, []call{
+		{Measurement: "cpu", FieldKey: "value", FieldVal: "1"},
+	})
+}
+
+func TestTokenizeFuncNoTrailingNewline(t *testing.T) {
+	c := qt.New(t)
+	calls, err := tokenizeFunc(c, "cpu value=1")
+	c.Assert(err, qt.IsNil)
+	c.Assert(calls, qt.DeepEquals, []call{
+		{Measurement: "cpu", FieldKey: "value", FieldVal: "1"},
+	})
+}
+
+var tokenizeFuncErrorTests = []struct {
+	testName    string
+	input       string
+	expectError string
+}{{
+	testName:    "no fields",
+	input:       "cpu,host=a\n",
+	expectError: `line-protocol: missing fields`,
+}, {
+	testName:    "tag without value",
+	input:       "cpu,host value=1\n",
+	expectError: `line-protocol: tag "host" without a value`,
+}, {
+	testName:    "unterminated string",
+	input:       `cpu value="unterminated` + "\n",
+	expectError: `line-protocol: unterminated string field value`,
+}, {
+	testName:    "junk at end of line",
+	input:       "cpu value=1 100junk\n",
+	expectError: `line-protocol: unexpected character 'j' at end of line`,
+}}
+
+func TestTokenizeFuncErrors(t *testing.T) {
+	c := qt.New(t)
+	for _, test := range tokenizeFuncErrorTests {
+		c.Run(test.testName, func(c *qt.C) {
+			_, err := tokenizeFunc(c, test.input)
+			c.Assert(err, qt.ErrorMatches, test.expectError)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package influxdata
+
+import "bytes"
+
+const (
+	// dedupHashBits sets the number of buckets in a SeriesDeduper's
+	// hash-chain head table.
+	dedupHashBits = 14
+	dedupHashSize = 1 << dedupHashBits
+
+	// dedupWindow bounds how far back a Lookup will walk a hash chain: a
+	// series not seen in the last dedupWindow Lookup calls is treated as
+	// new rather than as a repeat.
+	dedupWindow = 1 << 12
+
+	// dedupMaxOffset is the position at which a SeriesDeduper rebases its
+	// bookkeeping back down near zero, so that a long-running stream
+	// doesn't require ever-growing position counters. This mirrors the
+	// maxHashOffset/rebase technique compress/flate's compressor uses to
+	// keep its own hash-chain offsets bounded: once the current position
+	// moves far enough past the oldest entries still inside the window,
+	// every recorded position is shifted down by the same amount, and
+	// entries that fall outside the window as a result are dropped
+	// rather than rebased.
+	dedupMaxOffset = 1 << 24
+
+	// dedupMaxEntries is the point at which entries is compacted even if
+	// pos hasn't reached dedupMaxOffset, so that a high-cardinality
+	// stream (where most Lookup calls are misses) can't grow entries -
+	// and the key copies it holds - without bound between rebases.
+	dedupMaxEntries = 2 * dedupWindow
+)
+
+// seriesEntry is one interned series in a SeriesDeduper's hash chain.
+type seriesEntry struct {
+	pos  int    // position (see SeriesDeduper.pos) at which this entry was inserted
+	hash uint64 // full hash of key, checked before the byte comparison
+	id   uint32
+	key  []byte
+	prev int32 // index into entries of the next-older entry sharing this hash bucket, or -1
+}
+
+// SeriesDeduper interns the series key of each line-protocol line it's
+// shown - conventionally a measurement followed by its tags in sorted
+// order, in whatever encoding a caller uses consistently - and returns a
+// stable SeriesID for repeats. A caller that has already parsed a line's
+// tags (for example via TokenizeFunc) and assembled them into a sorted,
+// canonical key can use a SeriesDeduper to recognize when it's seeing a
+// series it has already processed earlier in the same batch, and skip
+// re-sorting and re-encoding the tags for it.
+//
+// A SeriesDeduper only remembers series seen within roughly the last
+// dedupWindow Lookup calls, and entries is compacted well before it
+// could grow much past that; like compress/flate's compressor, it
+// trades perfect recall for a bounded table size, which is the right
+// tradeoff for deduplicating the handful of distinct series that repeat
+// constantly within a batch rather than for an exact, unbounded cache. A
+// series that's looked up again before it ages out keeps its SeriesID
+// for as long as it keeps being looked up, however many times that is.
+type SeriesDeduper struct {
+	head    [dedupHashSize]int32 // index into entries of the most recently inserted key for this hash, or -1
+	entries []seriesEntry
+	pos     int // number of Lookup calls so far
+	nextID  uint32
+}
+
+// NewSeriesDeduper returns an empty SeriesDeduper.
+func NewSeriesDeduper() *SeriesDeduper {
+	d := &SeriesDeduper{}
+	for i := range d.head {
+		d.head[i] = -1
+	}
+	return d
+}
+
+// Lookup interns key and returns its SeriesID. ok reports whether this
+// exact key was already known; if so, canon is the key bytes retained
+// from that earlier call, so the caller can discard the one it just
+// built. If key is new, or the series has aged out of the window since
+// it was last seen, a copy of key is retained and returned as canon, and
+// a new SeriesID is allocated for it.
+//
+// A hit refreshes the series' position, as if it had just been inserted,
+// so that a series being looked up constantly - the usual case this
+// type is for - never ages out and keeps the same SeriesID for as long
+// as it keeps appearing.
+func (d *SeriesDeduper) Lookup(key []byte) (id uint32, canon []byte, ok bool) {
+	if d.pos >= dedupMaxOffset || len(d.entries) > dedupMaxEntries {
+		d.rebase()
+	}
+	h := dedupHash(key)
+	bucket := h & (dedupHashSize - 1)
+	minPos := d.pos - dedupWindow
+	for i := d.head[bucket]; i >= 0; {
+		e := d.entries[i]
+		if e.pos < minPos {
+			break
+		}
+		if e.hash == h && bytes.Equal(e.key, key) {
+			d.insert(h, bucket, e.id, e.key)
+			d.pos++
+			return e.id, e.key, true
+		}
+		i = e.prev
+	}
+	id = d.nextID
+	d.nextID++
+	canon = append([]byte(nil), key...)
+	d.insert(h, bucket, id, canon)
+	d.pos++
+	return id, canon, false
+}
+
+// insert links a new entry for (id, key) at the head of bucket's chain,
+// at the current position. It's used both for keys seen for the first
+// time and, to refresh their position, for repeats of keys already
+// known.
+func (d *SeriesDeduper) insert(hash uint64, bucket uint64, id uint32, key []byte) {
+	d.entries = append(d.entries, seriesEntry{
+		pos:  d.pos,
+		hash: hash,
+		id:   id,
+		key:  key,
+		prev: d.head[bucket],
+	})
+	d.head[bucket] = int32(len(d.entries) - 1)
+}
+
+// rebase shifts every recorded position down by delta, the position of
+// the oldest entry still inside the window, so that pos and the
+// positions stored in entries stay small even over a very long input.
+// Entries already outside the window are dropped rather than rebased.
+func (d *SeriesDeduper) rebase() {
+	delta := d.pos - dedupWindow
+	if delta <= 0 {
+		return
+	}
+	oldToNew := make([]int32, len(d.entries))
+	kept := d.entries[:0]
+	for i, e := range d.entries {
+		if e.pos < delta {
+			oldToNew[i] = -1
+			continue
+		}
+		e.pos -= delta
+		if e.prev >= 0 {
+			e.prev = oldToNew[e.prev]
+		}
+		oldToNew[i] = int32(len(kept))
+		kept = append(kept, e)
+	}
+	d.entries = kept
+	for i, head := range d.head {
+		if head < 0 {
+			continue
+		}
+		d.head[i] = oldToNew[head]
+	}
+	d.pos -= delta
+}
+
+// dedupHash computes the 64-bit FNV-1a hash of key.
+func dedupHash(key []byte) uint64 {
+	const (
+		offsetBasis = 14695981039346656037
+		prime       = 1099511628211
+	)
+	h := uint64(offsetBasis)
+	for _, c := range key {
+		h ^= uint64(c)
+		h *= prime
+	}
+	return h
+}
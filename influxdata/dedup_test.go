@@ -0,0 +1,103 @@
+package influxdata
+
+import (
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSeriesDeduperInternsRepeats(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	id1, key1, ok := d.Lookup([]byte("cpu,host=a"))
+	c.Assert(ok, qt.Equals, false)
+
+	id2, key2, ok := d.Lookup([]byte("cpu,host=a"))
+	c.Assert(ok, qt.Equals, true)
+	c.Assert(id2, qt.Equals, id1)
+	c.Assert(string(key2), qt.Equals, string(key1))
+}
+
+func TestSeriesDeduperDistinctKeys(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	id1, _, ok1 := d.Lookup([]byte("cpu,host=a"))
+	id2, _, ok2 := d.Lookup([]byte("cpu,host=b"))
+	c.Assert(ok1, qt.Equals, false)
+	c.Assert(ok2, qt.Equals, false)
+	c.Assert(id1 == id2, qt.Equals, false)
+}
+
+func TestSeriesDeduperWindowEviction(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	id1, _, ok := d.Lookup([]byte("cpu,host=a"))
+	c.Assert(ok, qt.Equals, false)
+
+	for i := 0; i < dedupWindow; i++ {
+		d.Lookup([]byte(fmt.Sprintf("cpu,host=filler%d", i)))
+	}
+
+	// The original key has now aged out of the window and should be
+	// treated as new rather than matched.
+	id2, _, ok := d.Lookup([]byte("cpu,host=a"))
+	c.Assert(ok, qt.Equals, false)
+	c.Assert(id2 == id1, qt.Equals, false)
+}
+
+func TestSeriesDeduperHotKeySurvivesPastWindow(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	id1, _, _ := d.Lookup([]byte("cpu,host=a"))
+	// Keep looking up the same hot key, interspersed with enough distinct
+	// filler keys to age out anything that isn't refreshed on every hit.
+	for i := 0; i < 3*dedupWindow; i++ {
+		d.Lookup([]byte(fmt.Sprintf("cpu,host=filler%d", i)))
+		id, _, ok := d.Lookup([]byte("cpu,host=a"))
+		c.Assert(ok, qt.Equals, true)
+		c.Assert(id, qt.Equals, id1)
+	}
+}
+
+func TestSeriesDeduperEntriesStayBounded(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	for i := 0; i < 10*dedupMaxEntries; i++ {
+		d.Lookup([]byte(fmt.Sprintf("cpu,host=filler%d", i)))
+	}
+	c.Assert(len(d.entries) <= dedupMaxEntries, qt.Equals, true)
+}
+
+func TestSeriesDeduperRebaseDropsOldEntries(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	id1, _, _ := d.Lookup([]byte("cpu,host=a"))
+	d.pos = dedupMaxOffset
+
+	id2, _, ok := d.Lookup([]byte("cpu,host=a"))
+	c.Assert(ok, qt.Equals, false)
+	c.Assert(id2 == id1, qt.Equals, false)
+}
+
+func TestSeriesDeduperRebaseKeepsRecentEntries(t *testing.T) {
+	c := qt.New(t)
+	d := NewSeriesDeduper()
+
+	id1, key1, _ := d.Lookup([]byte("cpu,host=a"))
+	// Pretend the entry was inserted just before the rebase threshold,
+	// well within the window, so it should survive the rebase.
+	d.entries[0].pos = dedupMaxOffset - 1
+	d.pos = dedupMaxOffset
+
+	id2, key2, ok := d.Lookup([]byte("cpu,host=a"))
+	c.Assert(ok, qt.Equals, true)
+	c.Assert(id2, qt.Equals, id1)
+	c.Assert(string(key2), qt.Equals, string(key1))
+}
@@ -0,0 +1,163 @@
+package influxdata
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errLineTooLong is the Err of a LineError produced when a line exceeds
+// MaxLineSize.
+var errLineTooLong = errors.New("line exceeds MaxLineSize")
+
+// LineError describes a single line-protocol line that NextLine could not
+// return in full.
+type LineError struct {
+	// Line is the 1-based index of the offending line, counting blank and
+	// comment lines as well as data lines.
+	Line int
+	// Col is the 1-based byte offset within the line at which the error
+	// was detected.
+	Col int
+	// Raw holds the raw bytes of the offending line seen so far; it may be
+	// truncated to MaxLineSize.
+	Raw []byte
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line-protocol: line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// NextLine advances to the next line-protocol line in the input, skipping
+// blank lines and comment lines (those starting with '#'), and reports
+// whether a line is available. Use LineBytes to retrieve it.
+//
+// If a line exceeds MaxLineSize, or the underlying reader fails, NextLine
+// builds a *LineError describing the problem and resyncs by discarding
+// input up to and including the next unescaped newline. When
+// ContinueOnError is false (the default), NextLine records that error
+// (retrievable from Err) and returns false, as it also does once the input
+// is exhausted. When ContinueOnError is true, it instead moves on to the
+// following line, so that a single malformed line doesn't abort the rest
+// of the stream; the *LineError is then discarded, and neither Err nor any
+// other method reports it.
+func (t *Tokenizer) NextLine() bool {
+	if t.frameErr != nil {
+		return false
+	}
+	for {
+		t.reset()
+		if !t.ensure(1) {
+			return false
+		}
+		t.line++
+		raw, err := t.scanLine()
+		if err != nil {
+			lineErr := &LineError{
+				Line: t.line,
+				Col:  len(raw) + 1,
+				Raw:  raw,
+				Err:  err,
+			}
+			if !t.ContinueOnError {
+				t.frameErr = lineErr
+				return false
+			}
+			continue
+		}
+		if len(raw) == 0 || raw[0] == '#' {
+			continue
+		}
+		t.lineBytes = raw
+		return true
+	}
+}
+
+// LineBytes returns the raw bytes of the line most recently returned by
+// NextLine, not including its trailing newline. The returned slice is
+// valid only until the next call to NextLine.
+func (t *Tokenizer) LineBytes() []byte {
+	return t.lineBytes
+}
+
+// Err returns the first error encountered by NextLine, either a *LineError
+// describing a malformed or oversized line, or an error from the
+// underlying reader. It returns nil if NextLine returned false because the
+// input was simply exhausted.
+func (t *Tokenizer) Err() error {
+	if t.frameErr != nil {
+		return t.frameErr
+	}
+	return t.err
+}
+
+// scanLine consumes one line's worth of raw bytes, up to and including the
+// next unescaped newline, and returns its content without that newline. It
+// enforces MaxLineSize as it goes, so that an adversarial input with no
+// newline can't grow the buffer without bound, and reports any error from
+// the underlying reader.
+func (t *Tokenizer) scanLine() ([]byte, error) {
+	start := t.r1
+	for {
+		if t.MaxLineSize > 0 && t.r1-start > t.MaxLineSize {
+			// Copy out the truncated raw bytes before resyncing: unlike
+			// scanLine's own loop, discardToNewline resets the buffer as
+			// it goes to bound memory, which would invalidate a slice
+			// still referencing the old start offset.
+			raw := append([]byte(nil), t.buf[start:start+t.MaxLineSize]...)
+			t.discardToNewline()
+			return raw, errLineTooLong
+		}
+		if !t.ensure(1) {
+			if t.err != nil {
+				return t.buf[start:t.r1], t.err
+			}
+			// End of input without a trailing newline: treat whatever
+			// remains as a complete final line.
+			return t.buf[start:t.r1], nil
+		}
+		switch {
+		case t.at(0) == '\n':
+			line := t.buf[start:t.r1]
+			t.advance(1)
+			return line, nil
+		case t.at(0) == '\\' && t.ensure(2) && t.at(1) == 'n':
+			t.advance(2)
+		default:
+			t.advance(1)
+		}
+	}
+}
+
+// discardToNewline consumes bytes up through the next unescaped newline,
+// so that scanLine can resync after a line that failed for some other
+// reason (currently, only for being too long). It resets periodically as
+// it goes, so that an adversarial input with no newline at all can't
+// grow buf without bound; that's also why it can't return the bytes it
+// discards, unlike scanLine's own loop.
+func (t *Tokenizer) discardToNewline() {
+	for {
+		if t.r1-t.r0 >= minRead {
+			t.reset()
+		}
+		if !t.ensure(1) {
+			t.reset()
+			return
+		}
+		switch {
+		case t.at(0) == '\n':
+			t.advance(1)
+			t.reset()
+			return
+		case t.at(0) == '\\' && t.ensure(2) && t.at(1) == 'n':
+			t.advance(2)
+		default:
+			t.advance(1)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package influxdata
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func readLines(t *Tokenizer) ([]string, error) {
+	var lines []string
+	for t.NextLine() {
+		lines = append(lines, string(t.LineBytes()))
+	}
+	return lines, t.Err()
+}
+
+func TestNextLine(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("cpu value=1\ncpu value=2\n"))
+	lines, err := readLines(tok)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lines, qt.DeepEquals, []string{"cpu value=1", "cpu value=2"})
+}
+
+func TestNextLineNoTrailingNewline(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("cpu value=1\ncpu value=2"))
+	lines, err := readLines(tok)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lines, qt.DeepEquals, []string{"cpu value=1", "cpu value=2"})
+}
+
+func TestNextLineSkipsBlankAndCommentLines(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("# a comment\n\ncpu value=1\n\n# another\n"))
+	lines, err := readLines(tok)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lines, qt.DeepEquals, []string{"cpu value=1"})
+}
+
+func TestNextLineEscapedNewlineIsNotATerminator(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader(`cpu value="a\nb"` + "\ncpu value=2\n"))
+	lines, err := readLines(tok)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lines, qt.DeepEquals, []string{`cpu value="a\nb"`, "cpu value=2"})
+}
+
+func TestNextLineMaxLineSize(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("cpu value=1\ncpu value=22222\ncpu value=3\n"))
+	tok.MaxLineSize = 12
+	c.Assert(tok.NextLine(), qt.Equals, true)
+	c.Assert(string(tok.LineBytes()), qt.Equals, "cpu value=1")
+	c.Assert(tok.NextLine(), qt.Equals, false)
+	var lineErr *LineError
+	c.Assert(tok.Err(), qt.ErrorAs, &lineErr)
+	c.Assert(lineErr.Line, qt.Equals, 2)
+	c.Assert(lineErr.Err, qt.Equals, errLineTooLong)
+}
+
+func TestNextLineMaxLineSizeContinueOnError(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("cpu value=1\ncpu value=22222\ncpu value=3\n"))
+	tok.MaxLineSize = 12
+	tok.ContinueOnError = true
+	lines, err := readLines(tok)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lines, qt.DeepEquals, []string{"cpu value=1", "cpu value=3"})
+}
+
+func TestNextLineResyncBoundsMemoryWithNoNewline(t *testing.T) {
+	c := qt.New(t)
+	// An adversarial input that never emits a newline: once it's past
+	// MaxLineSize, resyncing must bound how much it buffers rather than
+	// reading all the way to EOF looking for a terminator that never
+	// comes.
+	src := strings.Repeat("a", minRead*10)
+	tok := NewTokenizer(strings.NewReader(src))
+	tok.MaxLineSize = 50
+	c.Assert(tok.NextLine(), qt.Equals, false)
+	var lineErr *LineError
+	c.Assert(tok.Err(), qt.ErrorAs, &lineErr)
+	c.Assert(lineErr.Err, qt.Equals, errLineTooLong)
+	c.Assert(string(lineErr.Raw), qt.Equals, strings.Repeat("a", 50))
+	c.Assert(len(tok.buf) < minRead*2, qt.Equals, true)
+}
+
+func TestNextLineReaderError(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(&errorReader{
+		r:   strings.NewReader("cpu value=1\ncpu value=2"),
+		err: errLineTooLong,
+	})
+	c.Assert(tok.NextLine(), qt.Equals, true)
+	c.Assert(string(tok.LineBytes()), qt.Equals, "cpu value=1")
+	c.Assert(tok.NextLine(), qt.Equals, false)
+	var lineErr *LineError
+	c.Assert(tok.Err(), qt.ErrorAs, &lineErr)
+	c.Assert(lineErr.Err, qt.Equals, errLineTooLong)
+}
@@ -0,0 +1,85 @@
+package influxdata
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// pktLineHeaderSize is the size of a pkt-line length header: 4 bytes of
+// hexadecimal digits.
+const pktLineHeaderSize = 4
+
+// LengthPrefixedTokenizer reads a sequence of line-protocol records framed
+// in the pkt-line style used by git: each record is preceded by a 4-digit
+// hexadecimal header giving the total length, in bytes, of the header
+// plus the record that follows it, and a header of "0000" is a flush
+// packet that ends the stream. This gives an unambiguous framing for
+// records that may themselves contain raw newlines, unlike the
+// newline-delimited framing used by NextLine.
+type LengthPrefixedTokenizer struct {
+	t   *Tokenizer
+	rec []byte
+	err error
+}
+
+// NewLengthPrefixedTokenizer returns a LengthPrefixedTokenizer that reads
+// pkt-line-framed records from r.
+func NewLengthPrefixedTokenizer(r io.Reader) *LengthPrefixedTokenizer {
+	return &LengthPrefixedTokenizer{
+		t: NewTokenizer(r),
+	}
+}
+
+// Next reads the next record and reports whether one is available; use
+// Bytes to retrieve it. It returns false once a flush packet has been
+// read, the underlying reader is exhausted, or a framing error has
+// occurred, in which case Err returns the error.
+func (p *LengthPrefixedTokenizer) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	p.t.reset()
+	header, err := p.t.Peek(pktLineHeaderSize)
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return false
+		}
+		p.err = fmt.Errorf("line-protocol: short pkt-line header: %w", err)
+		return false
+	}
+	n, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		p.err = fmt.Errorf("line-protocol: invalid pkt-line header %q: %w", header, err)
+		return false
+	}
+	p.t.Discard(pktLineHeaderSize)
+	if n == 0 {
+		// Flush packet: end of stream.
+		return false
+	}
+	if n < pktLineHeaderSize {
+		p.err = fmt.Errorf("line-protocol: pkt-line header %q is shorter than the header itself", header)
+		return false
+	}
+	size := int(n) - pktLineHeaderSize
+	rec, err := p.t.Peek(size)
+	if err != nil {
+		p.err = fmt.Errorf("line-protocol: truncated pkt-line record: %w", err)
+		return false
+	}
+	p.t.Discard(size)
+	p.rec = rec
+	return true
+}
+
+// Bytes returns the record most recently read by Next. The returned
+// slice is valid only until the next call to Next.
+func (p *LengthPrefixedTokenizer) Bytes() []byte {
+	return p.rec
+}
+
+// Err returns the first error encountered while reading, if any.
+func (p *LengthPrefixedTokenizer) Err() error {
+	return p.err
+}
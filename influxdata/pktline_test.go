@@ -0,0 +1,46 @@
+package influxdata
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func pktLineRecords(p *LengthPrefixedTokenizer) ([]string, error) {
+	var recs []string
+	for p.Next() {
+		recs = append(recs, string(p.Bytes()))
+	}
+	return recs, p.Err()
+}
+
+func TestLengthPrefixedTokenizer(t *testing.T) {
+	c := qt.New(t)
+	p := NewLengthPrefixedTokenizer(strings.NewReader("000ccpu v=1\n000ccpu v=2\n0000"))
+	recs, err := pktLineRecords(p)
+	c.Assert(err, qt.IsNil)
+	c.Assert(recs, qt.DeepEquals, []string{"cpu v=1\n", "cpu v=2\n"})
+}
+
+func TestLengthPrefixedTokenizerNoFlushPacket(t *testing.T) {
+	c := qt.New(t)
+	p := NewLengthPrefixedTokenizer(strings.NewReader("000ccpu v=1\n"))
+	recs, err := pktLineRecords(p)
+	c.Assert(err, qt.IsNil)
+	c.Assert(recs, qt.DeepEquals, []string{"cpu v=1\n"})
+}
+
+func TestLengthPrefixedTokenizerInvalidHeader(t *testing.T) {
+	c := qt.New(t)
+	p := NewLengthPrefixedTokenizer(strings.NewReader("zzzzcpu v=1\n"))
+	_, err := pktLineRecords(p)
+	c.Assert(err, qt.ErrorMatches, `line-protocol: invalid pkt-line header "zzzz".*`)
+}
+
+func TestLengthPrefixedTokenizerTruncatedRecord(t *testing.T) {
+	c := qt.New(t)
+	p := NewLengthPrefixedTokenizer(strings.NewReader("0020short"))
+	_, err := pktLineRecords(p)
+	c.Assert(err, qt.ErrorMatches, `line-protocol: truncated pkt-line record: .*`)
+}
@@ -0,0 +1,438 @@
+// Package influxdata implements a tokenizer for the InfluxDB line protocol.
+package influxdata
+
+import "io"
+
+// minRead is the minimum number of bytes requested from the underlying
+// reader on each fill, and the initial capacity of a freshly allocated
+// buffer. Keeping it reasonably large amortizes the cost of small reads.
+const minRead = 8192
+
+// Tokenizer implements low level tokenization of an InfluxDB line-protocol
+// stream. It reads from an underlying reader into an internal buffer and
+// hands out slices into that buffer via take and takeEsc; those slices
+// remain valid only until the next call to reset.
+type Tokenizer struct {
+	// r is the source of data; nil when the tokenizer has been created
+	// directly from a byte slice.
+	r io.Reader
+
+	// buf holds all the data read so far that hasn't been discarded by
+	// reset.
+	buf []byte
+
+	// r0 marks the start of the current token region (the position as of
+	// the last reset).
+	r0 int
+
+	// r1 is the current scan position; data in buf[r0:r1] has already
+	// been consumed by take/takeEsc calls since the last reset.
+	r1 int
+
+	// err holds the first non-EOF error encountered while reading from r.
+	err error
+
+	// complete is true once there is no more data to be read, either
+	// because the underlying reader has returned EOF (or an error) or
+	// because the tokenizer was created directly from a byte slice.
+	complete bool
+
+	// skipping is set when the caller doesn't care about the unescaped
+	// content of a takeEsc call, only about how far it advances; in that
+	// mode no unescape buffer is allocated.
+	skipping bool
+
+	// MaxLineSize bounds the size of a single line as returned by
+	// NextLine; lines longer than this are reported as a *LineError
+	// rather than growing buf without limit. Zero means unlimited.
+	MaxLineSize int
+
+	// ContinueOnError makes NextLine resync past a malformed or
+	// oversized line and continue with the next one, instead of
+	// stopping at the first error.
+	ContinueOnError bool
+
+	// line counts the lines seen so far by NextLine, for LineError.Line.
+	line int
+
+	// lineBytes holds the raw content of the line most recently returned
+	// by NextLine.
+	lineBytes []byte
+
+	// frameErr holds the first error recorded by NextLine, once one has
+	// occurred.
+	frameErr error
+}
+
+// NewTokenizer returns a Tokenizer that reads data from r.
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{
+		r: r,
+	}
+}
+
+// NewTokenizerWithBytes returns a Tokenizer that consumes data directly
+// from the given byte slice. The slice must not be modified while the
+// Tokenizer is in use.
+func NewTokenizerWithBytes(data []byte) *Tokenizer {
+	return &Tokenizer{
+		buf:      data,
+		complete: true,
+	}
+}
+
+// fill reads more data into buf. It reports whether the read made bytes
+// available or returned no error; it returns false once the reader has
+// returned an error (including io.EOF, with any non-EOF error recorded in
+// err) without delivering any bytes. Once it returns false, it will always
+// return false on subsequent calls.
+func (t *Tokenizer) fill() bool {
+	if t.complete {
+		return false
+	}
+	if cap(t.buf)-len(t.buf) < minRead {
+		newCap := cap(t.buf) * 2
+		if newCap-len(t.buf) < minRead {
+			newCap = len(t.buf) + minRead
+		}
+		newBuf := make([]byte, len(t.buf), newCap)
+		copy(newBuf, t.buf)
+		t.buf = newBuf
+	}
+	n, err := t.r.Read(t.buf[len(t.buf):cap(t.buf)])
+	t.buf = t.buf[:len(t.buf)+n]
+	if err != nil {
+		if err != io.EOF {
+			t.err = err
+		}
+		t.complete = true
+	}
+	return n > 0 || err == nil
+}
+
+// ensure reports whether there are at least n unconsumed bytes available
+// from the current scan position, reading more data as needed. It returns
+// false if the underlying reader is exhausted (or errored) before n bytes
+// could be made available.
+func (t *Tokenizer) ensure(n int) bool {
+	for len(t.buf)-t.r1 < n {
+		if !t.fill() {
+			return false
+		}
+	}
+	return true
+}
+
+// at returns the byte at offset i from the current scan position. The
+// caller must have already ensured that the byte is available.
+func (t *Tokenizer) at(i int) byte {
+	return t.buf[t.r1+i]
+}
+
+// advance moves the scan position forward by n bytes without including
+// them in the result of any take/takeEsc call.
+func (t *Tokenizer) advance(n int) {
+	t.r1 += n
+}
+
+// Peek returns the next n bytes from the current scan position without
+// consuming them, reading from the underlying reader as necessary. The
+// returned slice is valid only until the next call to reset, fill or
+// Discard. If fewer than n bytes are available, Peek returns as many as
+// it could get along with the error (io.EOF, unless the underlying reader
+// failed some other way) that stopped it from reading the rest.
+func (t *Tokenizer) Peek(n int) ([]byte, error) {
+	if t.ensure(n) {
+		return t.buf[t.r1 : t.r1+n], nil
+	}
+	if t.err != nil {
+		return t.buf[t.r1:], t.err
+	}
+	return t.buf[t.r1:], io.EOF
+}
+
+// Unread moves the scan position back by n bytes, so that they will be
+// produced again by subsequent calls to take, takeEsc, Peek or Discard.
+// It panics if that would move the scan position before the start of the
+// data retained since the last reset, since those bytes may no longer be
+// available.
+func (t *Tokenizer) Unread(n int) {
+	if t.r1-n < t.r0 {
+		panic("influxdata: Unread past last reset")
+	}
+	t.r1 -= n
+}
+
+// Discard skips the next n bytes from the current scan position, reading
+// from the underlying reader as necessary, and returns the number of
+// bytes actually discarded. If fewer than n bytes were available, it
+// returns that count along with the error that stopped it from reading
+// the rest.
+func (t *Tokenizer) Discard(n int) (int, error) {
+	if t.ensure(n) {
+		t.advance(n)
+		return n, nil
+	}
+	discarded := len(t.buf) - t.r1
+	t.advance(discarded)
+	if t.err != nil {
+		return discarded, t.err
+	}
+	return discarded, io.EOF
+}
+
+// reset discards all data before the current scan position, so that it can
+// be reused for the buffer of subsequent reads. Any slices returned by
+// take/takeEsc before the call to reset must not be used afterwards.
+func (t *Tokenizer) reset() {
+	if t.r == nil {
+		// The data is already fully resident in buf (NewTokenizerWithBytes)
+		// and there's nothing more to read into it, so there's no benefit
+		// to reclaiming the consumed prefix; avoid the O(n) copy.
+		t.r0 = t.r1
+		return
+	}
+	n := copy(t.buf, t.buf[t.r1:])
+	t.buf = t.buf[:n]
+	t.r0 = 0
+	t.r1 = 0
+}
+
+// take consumes and returns the longest run of bytes that are members of
+// bs, starting at the current scan position. It returns an empty slice if
+// there are no such bytes, either because the tokenizer is exhausted or
+// because the next byte isn't in bs.
+func (t *Tokenizer) take(bs *byteSet) []byte {
+	start := t.r1
+	for {
+		if t.r1 < len(t.buf) {
+			t.r1 += scanSet(t.buf[t.r1:], bs)
+			if t.r1 < len(t.buf) {
+				return t.buf[start:t.r1]
+			}
+		}
+		if !t.fill() {
+			return t.buf[start:t.r1]
+		}
+	}
+}
+
+// scanSetScalar returns the number of leading bytes of buf that are
+// members of bs, checked one byte at a time. It's used directly on
+// platforms with no vectorized scanSet, and as the fallback for the
+// trailing bytes left over after one on platforms that have it.
+func scanSetScalar(buf []byte, bs *byteSet) int {
+	i := 0
+	for i < len(buf) && bs.get(buf[i]) {
+		i++
+	}
+	return i
+}
+
+// takeEsc is like take except that it also consumes backslash-escaped
+// bytes, as described by escRevTable, regardless of whether they're
+// members of bs; the escaped form is decoded in the result. A backslash
+// followed by a byte that isn't in escRevTable is left as a literal
+// backslash and the following byte is then considered for membership of
+// bs as usual. A backslash followed by EOF is taken as a literal
+// backslash.
+//
+// When t.skipping is true, the returned data isn't unescaped (that's
+// unnecessary extra work when the caller doesn't need the content) but the
+// scan position is advanced exactly as it would be otherwise.
+func (t *Tokenizer) takeEsc(bs *byteSet, escRevTable *escapeTable) []byte {
+	start := t.r1
+	flushed := start
+	var out []byte
+	for {
+		if t.r1 >= len(t.buf) {
+			if !t.fill() {
+				break
+			}
+			continue
+		}
+		c := t.buf[t.r1]
+		if c != '\\' {
+			if !bs.get(c) {
+				break
+			}
+			t.r1++
+			continue
+		}
+		if t.r1+1 >= len(t.buf) {
+			if !t.fill() {
+				// A trailing backslash with nothing following it is
+				// taken as a literal backslash.
+				t.r1++
+				break
+			}
+			continue
+		}
+		if v, ok := escRevTable.get(t.buf[t.r1+1]); ok {
+			if !t.skipping {
+				out = append(out, t.buf[flushed:t.r1]...)
+				out = append(out, v)
+			}
+			t.r1 += 2
+			flushed = t.r1
+			continue
+		}
+		// Not a recognized escape: the backslash is literal and the
+		// following byte is reconsidered as an ordinary byte.
+		t.r1++
+	}
+	if t.skipping || out == nil {
+		return t.buf[start:t.r1]
+	}
+	return append(out, t.buf[flushed:t.r1]...)
+}
+
+// TakeInto is like takeEsc except that, instead of returning a slice of
+// the tokenizer's own buffer (or of a freshly allocated one), it appends
+// the unescaped result to *dst, growing it as needed, and returns the
+// appended portion. This lets a caller that needs the result to outlive
+// more than one token - for example to buffer several fields until a
+// later part of the line has been parsed - reuse a single buffer across
+// many calls instead of taking on a fresh allocation each time.
+func (t *Tokenizer) TakeInto(dst *[]byte, bs *byteSet, escRevTable *escapeTable) []byte {
+	start := len(*dst)
+	flushed := t.r1
+	for {
+		if t.r1 >= len(t.buf) {
+			if !t.fill() {
+				break
+			}
+			continue
+		}
+		c := t.buf[t.r1]
+		if c != '\\' {
+			if !bs.get(c) {
+				break
+			}
+			t.r1++
+			continue
+		}
+		if t.r1+1 >= len(t.buf) {
+			if !t.fill() {
+				t.r1++
+				break
+			}
+			continue
+		}
+		if v, ok := escRevTable.get(t.buf[t.r1+1]); ok {
+			*dst = append(*dst, t.buf[flushed:t.r1]...)
+			*dst = append(*dst, v)
+			t.r1 += 2
+			flushed = t.r1
+			continue
+		}
+		t.r1++
+	}
+	*dst = append(*dst, t.buf[flushed:t.r1]...)
+	return (*dst)[start:]
+}
+
+// byteSet represents a set of byte values as a 256-bit bitmap, along with
+// the nibble-indexed lookup tables used by the vectorized scanners in
+// tokenizer_simd_amd64.go to classify 16 bytes at a time. The tables are
+// kept in sync with bits by rebuildNibbleTables, which set and invert call whenever
+// the set's membership changes.
+type byteSet struct {
+	bits [4]uint64
+
+	// lo and hi split the bitmap by the top bit of the byte: lo[n] holds,
+	// in bit h, whether byte (h<<4)|n is a member, for h in 0..7; hi[n]
+	// holds the same for byte ((h+8)<<4)|n. Together with the low nibble
+	// and high nibble of a byte, a PSHUFB of each table plus a blend on
+	// the byte's sign bit recovers set membership.
+	lo, hi [16]byte
+}
+
+// newByteSet returns a byteSet containing exactly the given bytes.
+func newByteSet(bytes ...byte) *byteSet {
+	var bs byteSet
+	for _, b := range bytes {
+		bs.set(b)
+	}
+	return &bs
+}
+
+// set adds c to the set.
+func (bs *byteSet) set(c byte) {
+	bs.bits[c>>6] |= 1 << (c & 63)
+	bs.rebuildNibbleTables()
+}
+
+// get reports whether c is a member of the set.
+func (bs *byteSet) get(c byte) bool {
+	return bs.bits[c>>6]&(1<<(c&63)) != 0
+}
+
+// rebuildNibbleTables recomputes lo and hi from bits.
+func (bs *byteSet) rebuildNibbleTables() {
+	for n := 0; n < 16; n++ {
+		var rowLo, rowHi byte
+		for h := 0; h < 8; h++ {
+			if bs.get(byte(h<<4 | n)) {
+				rowLo |= 1 << uint(h)
+			}
+			if bs.get(byte((h+8)<<4 | n)) {
+				rowHi |= 1 << uint(h)
+			}
+		}
+		bs.lo[n] = rowLo
+		bs.hi[n] = rowHi
+	}
+}
+
+// invert replaces the set with its complement and returns it, so that
+// calls can be chained as in newByteSet('a', 'b').invert().
+func (bs *byteSet) invert() *byteSet {
+	for i := range bs.bits {
+		bs.bits[i] = ^bs.bits[i]
+	}
+	bs.rebuildNibbleTables()
+	return bs
+}
+
+// escapeTable maps the byte that follows a backslash to the literal byte
+// it represents. Bytes with no single-letter mnemonic (for example space,
+// comma or equals) are escaped as themselves; '\t', '\n' and '\r' are
+// escaped using their usual letter mnemonics, as in Go string literals.
+type escapeTable struct {
+	valid [256]bool
+	table [256]byte
+}
+
+// get reports whether c (the byte following a backslash) is a recognized
+// escape and, if so, returns the literal byte it represents.
+func (t *escapeTable) get(c byte) (byte, bool) {
+	return t.table[c], t.valid[c]
+}
+
+// escaper holds the tables needed to escape and unescape a given set of
+// characters.
+type escaper struct {
+	revTable escapeTable
+}
+
+// newEscaper returns an escaper for unescaping the given set of
+// characters, each of which may appear after a backslash in escaped form.
+func newEscaper(chars string) *escaper {
+	e := &escaper{}
+	for i := 0; i < len(chars); i++ {
+		c := chars[i]
+		key := c
+		switch c {
+		case '\t':
+			key = 't'
+		case '\n':
+			key = 'n'
+		case '\r':
+			key = 'r'
+		}
+		e.revTable.valid[key] = true
+		e.revTable.table[key] = c
+	}
+	return e
+}
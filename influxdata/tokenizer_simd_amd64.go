@@ -0,0 +1,38 @@
+//go:build amd64
+
+package influxdata
+
+import "golang.org/x/sys/cpu"
+
+// simdChunk is the number of bytes scanSetChunk classifies per call.
+const simdChunk = 16
+
+// hasSIMDScan reports whether the CPU supports the SSSE3 (PSHUFB) and
+// SSE4.1 (PBLENDVB) instructions that scanSetChunk relies on. It plays
+// the same role as internal/cpu does inside the standard library, but
+// that package isn't importable outside it, so golang.org/x/sys/cpu is
+// used instead.
+var hasSIMDScan = cpu.X86.HasSSSE3 && cpu.X86.HasSSE41
+
+// scanSetChunk classifies 16 bytes at once against bs, implemented in
+// tokenizer_simd_amd64.s. It's only called when hasSIMDScan is true.
+func scanSetChunk(p *byte, lo, hi *[16]byte) int
+
+// scanSet returns the number of leading bytes of buf that are members of
+// bs, using scanSetChunk to classify 16 bytes at a time and falling back
+// to the scalar loop for any trailing bytes, or for the whole buffer on
+// CPUs that lack the required instructions.
+func scanSet(buf []byte, bs *byteSet) int {
+	if !hasSIMDScan {
+		return scanSetScalar(buf, bs)
+	}
+	n := 0
+	for len(buf)-n >= simdChunk {
+		adv := scanSetChunk(&buf[n], &bs.lo, &bs.hi)
+		n += adv
+		if adv < simdChunk {
+			return n
+		}
+	}
+	return n + scanSetScalar(buf[n:], bs)
+}
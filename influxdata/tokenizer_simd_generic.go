@@ -0,0 +1,10 @@
+//go:build !amd64
+
+package influxdata
+
+// scanSet returns the number of leading bytes of buf that are members of
+// bs. GOARCH has no vectorized implementation, so this falls back to the
+// scalar loop directly; see tokenizer_simd_amd64.go for the fast path.
+func scanSet(buf []byte, bs *byteSet) int {
+	return scanSetScalar(buf, bs)
+}
@@ -0,0 +1,49 @@
+package influxdata
+
+import (
+	"math/rand"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// TestScanSetAgreesWithScalar checks that scanSet (which may use the
+// vectorized amd64 path) always agrees with scanSetScalar, across a range
+// of byte sets and inputs that exercise chunk boundaries and both the lo
+// and hi nibble tables.
+func TestScanSetAgreesWithScalar(t *testing.T) {
+	c := qt.New(t)
+	sets := []*byteSet{
+		newByteSet(),
+		newByteSet().invert(),
+		newByteSet('a', 'b', 'c'),
+		newByteSet(' ', '\t', '\n'),
+		newByteSet(' ', '\t', '\n').invert(),
+		newByteSet(0, 1, 2, 0x7f, 0x80, 0xff),
+		newByteSet('=', ',').invert(),
+	}
+	rnd := rand.New(rand.NewSource(0))
+	for _, bs := range sets {
+		for _, n := range []int{0, 1, 15, 16, 17, 31, 32, 33, 100} {
+			buf := make([]byte, n)
+			rnd.Read(buf)
+			want := scanSetScalar(buf, bs)
+			got := scanSet(buf, bs)
+			c.Assert(got, qt.Equals, want, qt.Commentf("n=%d buf=%x", n, buf))
+		}
+	}
+}
+
+// TestScanSetAllMembers checks the common case of a buffer that's a
+// multiple of the SIMD chunk size and entirely made up of set members, so
+// that scanSet must read all the way to the end without a reader to fall
+// back on.
+func TestScanSetAllMembers(t *testing.T) {
+	c := qt.New(t)
+	bs := newByteSet('a')
+	buf := make([]byte, 64)
+	for i := range buf {
+		buf[i] = 'a'
+	}
+	c.Assert(scanSet(buf, bs), qt.Equals, len(buf))
+}
@@ -163,6 +163,41 @@ func TestTokenizerTakeEscSkipping(t *testing.T) {
 	c.Assert(string(data), qt.Equals, `hello\ \t\\z\`)
 }
 
+func TestTokenizerPeekDiscardUnread(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("abcdef"))
+
+	peeked, err := tok.Peek(3)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(peeked), qt.Equals, "abc")
+
+	n, err := tok.Discard(2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 2)
+	c.Assert(tok.at(0), qt.Equals, byte('c'))
+
+	tok.advance(1)
+	tok.Unread(1)
+	c.Assert(tok.at(0), qt.Equals, byte('c'))
+
+	peeked, err = tok.Peek(10)
+	c.Assert(err, qt.Equals, io.EOF)
+	c.Assert(string(peeked), qt.Equals, "cdef")
+
+	n, err = tok.Discard(10)
+	c.Assert(err, qt.Equals, io.EOF)
+	c.Assert(n, qt.Equals, 4)
+}
+
+func TestTokenizerUnreadPastResetPanics(t *testing.T) {
+	c := qt.New(t)
+	tok := NewTokenizer(strings.NewReader("abcdef"))
+	tok.take(newByteSet('a', 'b').invert())
+	tok.advance(2)
+	tok.reset()
+	c.Assert(func() { tok.Unread(1) }, qt.PanicMatches, "influxdata: Unread past last reset")
+}
+
 type errorReader struct {
 	r   io.Reader
 	err error